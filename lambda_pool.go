@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/redis/go-redis/v9"
+)
+
+// lambdaJob is one stream message queued for invocation.
+type lambdaJob struct {
+	stream  string
+	message redis.XMessage
+	attempt int64
+}
+
+// lambdaWorkerPool fans queued messages out across a fixed number of
+// goroutines so Lambda round-trip latency no longer serializes the read
+// loop. The jobs channel is bounded to MAX_CONCURRENT_INVOCATIONS, so
+// Submit blocks once every worker is busy and that many more jobs are
+// queued, which is what gives the reader its back-pressure.
+type lambdaWorkerPool struct {
+	lambdaClient   *lambda.Lambda
+	lambdaName     string
+	invocationType string
+	consumerGroup  string
+	encoder        PayloadEncoder
+	jobs           chan lambdaJob
+	ackers         map[string]*ackBatcher
+	wg             sync.WaitGroup
+}
+
+// newLambdaWorkerPool starts size workers pulling from a channel of the same
+// capacity, so a full pool naturally blocks new submissions instead of
+// piling up unbounded work in memory.
+func newLambdaWorkerPool(size int, lambdaClient *lambda.Lambda, lambdaName, invocationType, consumerGroup string, encoder PayloadEncoder, ackers map[string]*ackBatcher) *lambdaWorkerPool {
+	pool := &lambdaWorkerPool{
+		lambdaClient:   lambdaClient,
+		lambdaName:     lambdaName,
+		invocationType: invocationType,
+		consumerGroup:  consumerGroup,
+		encoder:        encoder,
+		jobs:           make(chan lambdaJob, size),
+		ackers:         ackers,
+	}
+
+	for i := 0; i < size; i++ {
+		go pool.worker()
+	}
+
+	return pool
+}
+
+// Submit enqueues a first-delivery message for invocation, blocking if every
+// worker is busy and the jobs queue is full. It must not be called
+// concurrently with Shutdown.
+func (p *lambdaWorkerPool) Submit(stream string, message redis.XMessage) {
+	p.SubmitWithAttempt(stream, message, 1)
+}
+
+// SubmitWithAttempt enqueues a message for invocation, recording attempt as
+// its delivery count (used by the reclaim loop to resubmit a claimed entry
+// instead of letting it dead-letter on a timer without ever being retried).
+// It must not be called concurrently with Shutdown.
+func (p *lambdaWorkerPool) SubmitWithAttempt(stream string, message redis.XMessage, attempt int64) {
+	p.wg.Add(1)
+	p.jobs <- lambdaJob{stream: stream, message: message, attempt: attempt}
+}
+
+// Shutdown stops accepting new work and waits for every in-flight invocation
+// to finish, giving up once ctx expires so a stuck Lambda invocation can't
+// block the process from exiting.
+func (p *lambdaWorkerPool) Shutdown(ctx context.Context) {
+	close(p.jobs)
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Printf("All in-flight Lambda invocations completed")
+	case <-ctx.Done():
+		log.Printf("Shutdown deadline reached with in-flight Lambda invocations still running")
+	}
+}
+
+func (p *lambdaWorkerPool) worker() {
+	for job := range p.jobs {
+		p.process(job)
+	}
+}
+
+func (p *lambdaWorkerPool) process(job lambdaJob) {
+	defer p.wg.Done()
+
+	log.Printf("Processing message ID: %s from stream: %s", job.message.ID, job.stream)
+	messagesReadTotal.WithLabelValues(job.stream).Inc()
+
+	envelope := buildEnvelope(job.stream, job.message.ID, time.Now().UnixMilli(), p.consumerGroup, job.attempt, job.message.Values)
+	payload, err := p.encoder.Encode(envelope)
+	if err != nil {
+		log.Printf("Error encoding payload for message ID %s on stream %s: %v", job.message.ID, job.stream, err)
+		messagesFailedTotal.WithLabelValues(job.stream).Inc()
+		return
+	}
+
+	start := time.Now()
+	err = invokeLambda(p.lambdaClient, p.lambdaName, p.invocationType, payload)
+	lambdaInvocationDuration.WithLabelValues(job.stream).Observe(time.Since(start).Seconds())
+	if err != nil {
+		log.Printf("Error invoking Lambda: %v", err)
+		observeLambdaError(job.stream, err)
+		messagesFailedTotal.WithLabelValues(job.stream).Inc()
+		return
+	}
+
+	if acker, ok := p.ackers[job.stream]; ok {
+		acker.Ack(job.message.ID)
+	}
+}
+
+// loadConcurrency reads MAX_CONCURRENT_INVOCATIONS, defaulting to 10 workers.
+func loadConcurrency() int {
+	return envInt("MAX_CONCURRENT_INVOCATIONS", 10)
+}
+
+// loadInvocationType reads INVOCATION_TYPE, defaulting to the synchronous
+// RequestResponse behavior the binary has always had.
+func loadInvocationType() string {
+	if v := os.Getenv("INVOCATION_TYPE"); v != "" {
+		return v
+	}
+	return "RequestResponse"
+}
+
+// ackBatcher coalesces XAcks for a single stream, flushing a pipelined XAck
+// whenever it accumulates maxSize message IDs or flushEvery elapses,
+// whichever comes first. This trades a small ack-visibility delay for far
+// fewer Redis round-trips under load.
+type ackBatcher struct {
+	rdb           redis.UniversalClient
+	stream        string
+	consumerGroup string
+	maxSize       int
+	flushEvery    time.Duration
+	ids           chan string
+	flushNow      chan flushRequest
+}
+
+// flushRequest asks run's loop to flush immediately using ctx (rather than
+// the package-level ctx, which may already be cancelled during shutdown),
+// signaling done once the flush completes.
+type flushRequest struct {
+	ctx  context.Context
+	done chan struct{}
+}
+
+// newAckBatcher creates a batcher and starts its flush loop; callers should
+// call Ack for every successfully processed message ID.
+func newAckBatcher(rdb redis.UniversalClient, stream, consumerGroup string, maxSize int, flushEvery time.Duration) *ackBatcher {
+	b := &ackBatcher{
+		rdb:           rdb,
+		stream:        stream,
+		consumerGroup: consumerGroup,
+		maxSize:       maxSize,
+		flushEvery:    flushEvery,
+		ids:           make(chan string, maxSize),
+		flushNow:      make(chan flushRequest),
+	}
+	go b.run()
+	return b
+}
+
+// Ack queues a message ID to be acknowledged on the next flush.
+func (b *ackBatcher) Ack(id string) {
+	b.ids <- id
+}
+
+// FlushAndWait forces an immediate flush of whatever is pending using ctx and
+// blocks until it completes, for use while draining on shutdown (when the
+// package-level ctx is already cancelled).
+func (b *ackBatcher) FlushAndWait(ctx context.Context) {
+	done := make(chan struct{})
+	b.flushNow <- flushRequest{ctx: ctx, done: done}
+	<-done
+}
+
+func (b *ackBatcher) run() {
+	ticker := time.NewTicker(b.flushEvery)
+	defer ticker.Stop()
+
+	pending := make([]string, 0, b.maxSize)
+	for {
+		select {
+		case id := <-b.ids:
+			pending = append(pending, id)
+			if len(pending) >= b.maxSize {
+				pending = b.flush(ctx, pending)
+			}
+		case <-ticker.C:
+			pending = b.flush(ctx, pending)
+		case req := <-b.flushNow:
+			// Pick up IDs already queued by Ack before flushing.
+			for drained := false; !drained; {
+				select {
+				case id := <-b.ids:
+					pending = append(pending, id)
+				default:
+					drained = true
+				}
+			}
+			pending = b.flush(req.ctx, pending)
+			close(req.done)
+		}
+	}
+}
+
+// flush pipelines an XAck for every pending ID and returns a reset slice
+// reusing the same backing array. On error the IDs are kept pending rather
+// than discarded, since their Lambda invocations already succeeded — dropping
+// them here would leave those messages to be reclaimed and eventually
+// dead-lettered despite having been processed.
+func (b *ackBatcher) flush(ctx context.Context, pending []string) []string {
+	if len(pending) == 0 {
+		return pending
+	}
+
+	_, err := b.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, id := range pending {
+			pipe.XAck(ctx, b.stream, b.consumerGroup, id)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error batch-acking %d messages on stream %s, will retry: %v", len(pending), b.stream, err)
+		return pending
+	}
+
+	log.Printf("Acknowledged %d messages in consumer group %s on stream %s", len(pending), b.consumerGroup, b.stream)
+	messagesAckedTotal.WithLabelValues(b.stream).Add(float64(len(pending)))
+	return pending[:0]
+}
+
+// loadAckBatchConfig reads the batching knobs, defaulting to a small batch
+// size and short flush interval so ack latency stays low at rest.
+func loadAckBatchConfig() (maxSize int, flushEvery time.Duration) {
+	maxSize = envInt("ACK_BATCH_SIZE", 20)
+
+	flushEvery = 500 * time.Millisecond
+	if v := os.Getenv("ACK_FLUSH_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			flushEvery = d
+		} else {
+			log.Printf("Invalid ACK_FLUSH_INTERVAL %q, using default %s: %v", v, flushEvery, err)
+		}
+	}
+
+	return maxSize, flushEvery
+}
@@ -2,10 +2,13 @@ package main
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"log"
 	"os"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
@@ -13,16 +16,25 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
-var ctx = context.Background()
+// ctx is cancelled once a shutdown signal arrives; it's threaded through the
+// Redis calls of the read, reclaim, ack and metrics loops so in-flight
+// commands unblock as soon as shutdown starts. The final ack flush during
+// shutdown uses its own deadline context instead.
+var ctx context.Context
 
 func main() {
+	var stop context.CancelFunc
+	ctx, stop = signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Parse input arguments
 	redisURL := os.Getenv("REDIS_URL")           // Redis DB URL
 	streams := os.Getenv("REDIS_STREAMS")        // Comma-separated list of streams
 	consumerGroup := os.Getenv("CONSUMER_GROUP") // Consumer group name
 	lambdaName := os.Getenv("LAMBDA_NAME")       // AWS Lambda function name
+	consumerName := consumerNameFromEnv()        // This replica's consumer identity
 
-	// Initialize Redis client
+	// Initialize Redis client (standalone, cluster, or sentinel)
 	rdb := initializeRedisClient(redisURL)
 	defer rdb.Close()
 
@@ -36,48 +48,112 @@ func main() {
 	sess := session.Must(session.NewSession())
 	lambdaClient := lambda.New(sess)
 
-	// Consume stream messages
+	// One ack batcher per stream, coalescing XAcks into pipelined batches.
+	ackBatchSize, ackFlushInterval := loadAckBatchConfig()
+	ackers := make(map[string]*ackBatcher, len(streamList))
+	for _, stream := range streamList {
+		ackers[stream] = newAckBatcher(rdb, stream, consumerGroup, ackBatchSize, ackFlushInterval)
+	}
+
+	// Worker pool fans messages out to Lambda invocations; its bounded jobs
+	// channel is what gives the read loop back-pressure.
+	pool := newLambdaWorkerPool(loadConcurrency(), lambdaClient, lambdaName, loadInvocationType(), consumerGroup, selectPayloadEncoder(), ackers)
+
+	// Start a reclaim loop per stream so entries abandoned by a crashed
+	// consumer get resubmitted to the pool for an actual retry instead of
+	// sitting in the PEL forever. backgroundLoops tracks these so shutdown
+	// can wait for them to stop before pool.Shutdown closes the jobs channel
+	// they submit to.
+	var backgroundLoops sync.WaitGroup
+	reclaimCfg := loadReclaimConfig()
+	for _, stream := range streamList {
+		backgroundLoops.Add(1)
+		go func(stream string) {
+			defer backgroundLoops.Done()
+			runReclaimLoop(rdb, stream, consumerGroup, consumerName, reclaimCfg, pool)
+		}(stream)
+	}
+
+	// Prometheus /metrics endpoint and per-group health gauges
+	startMetricsServer(os.Getenv("METRICS_ADDR"))
+	groupMetricsInterval := loadGroupMetricsInterval()
+	for _, stream := range streamList {
+		backgroundLoops.Add(1)
+		go func(stream string) {
+			defer backgroundLoops.Done()
+			runGroupMetricsLoop(rdb, stream, consumerGroup, groupMetricsInterval)
+		}(stream)
+	}
+
+	// Consume stream messages. Block is bounded (instead of 0/forever) so the
+	// loop wakes up periodically to notice ctx has been cancelled.
+	readBlockTimeout := loadReadBlockTimeout()
+readLoop:
 	for {
+		select {
+		case <-ctx.Done():
+			break readLoop
+		default:
+		}
+
 		entries, err := rdb.XReadGroup(ctx, &redis.XReadGroupArgs{
 			Group:    consumerGroup,
-			Consumer: "consumer-1", // You can replace with a dynamic consumer name
+			Consumer: consumerName,
 			Streams:  append(streamList, ">"),
 			Count:    10,
-			Block:    0,
+			Block:    readBlockTimeout,
 		}).Result()
 
 		if err != nil {
+			if ctx.Err() != nil {
+				break readLoop
+			}
+			if errors.Is(err, redis.Nil) {
+				continue // Block elapsed with no new entries
+			}
 			log.Printf("Error reading from Redis stream: %v", err)
 			continue
 		}
 
-		// Process each entry and invoke AWS Lambda
+		// Hand each entry to the worker pool; Submit blocks once the pool is
+		// saturated, which throttles how far ahead the reader can get.
 		for _, entry := range entries {
 			for _, message := range entry.Messages {
-				log.Printf("Processing message ID: %s from stream: %s", message.ID, entry.Stream)
-
-				err = invokeLambda(lambdaClient, lambdaName, message)
-				if err != nil {
-					log.Printf("Error invoking Lambda: %v", err)
-				} else {
-					// Acknowledge message
-					rdb.XAck(ctx, entry.Stream, consumerGroup, message.ID)
-					log.Printf("Message ID %s acknowledged in consumer group %s", message.ID, consumerGroup)
-				}
+				pool.Submit(entry.Stream, message)
 			}
 		}
 	}
-}
 
-// initializeRedisClient initializes the Redis client with connection pool settings
-func initializeRedisClient(redisURL string) *redis.Client {
-	opts, err := redis.ParseURL(redisURL)
-	if err != nil {
-		log.Fatalf("Failed to parse Redis URL: %v", err)
+	log.Printf("Shutdown signal received, draining in-flight work...")
+
+	// Wait for the reclaim/metrics loops to notice ctx is cancelled and
+	// return before shutting down the pool, so none of them can submit to
+	// (or the ack batchers flush against) state that's being torn down.
+	backgroundLoops.Wait()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), loadShutdownDeadline())
+	defer cancel()
+
+	pool.Shutdown(shutdownCtx)
+	for _, stream := range streamList {
+		ackers[stream].FlushAndWait(shutdownCtx)
 	}
 
-	rdb := redis.NewClient(opts)
-	return rdb
+	log.Printf("Shutdown complete")
+}
+
+// consumerNameFromEnv returns this replica's consumer name. CONSUMER_NAME lets
+// operators pin a stable identity (e.g. the pod name); otherwise we derive one
+// from the hostname so multiple replicas don't collide on "consumer-1".
+func consumerNameFromEnv() string {
+	if name := os.Getenv("CONSUMER_NAME"); name != "" {
+		return name
+	}
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return "consumer-1"
+	}
+	return host
 }
 
 // Split streams by comma
@@ -86,7 +162,7 @@ func splitStreams(streams string) []string {
 }
 
 // ensureConsumerGroupExists checks if the consumer group exists and creates it if necessary
-func ensureConsumerGroupExists(rdb *redis.Client, stream string, consumerGroup string) {
+func ensureConsumerGroupExists(rdb redis.UniversalClient, stream string, consumerGroup string) {
 	// Try to create the consumer group
 	err := rdb.XGroupCreateMkStream(ctx, stream, consumerGroup, "$").Err()
 	if err != nil {
@@ -101,12 +177,12 @@ func ensureConsumerGroupExists(rdb *redis.Client, stream string, consumerGroup s
 	}
 }
 
-// Invoke the AWS Lambda function
-func invokeLambda(lambdaClient *lambda.Lambda, functionName string, message redis.XMessage) error {
-	payload := []byte(fmt.Sprintf(`{"message": "%v"}`, message.Values))
+// Invoke the AWS Lambda function with an already-encoded payload
+func invokeLambda(lambdaClient *lambda.Lambda, functionName, invocationType string, payload []byte) error {
 	result, err := lambdaClient.Invoke(&lambda.InvokeInput{
-		FunctionName: aws.String(functionName),
-		Payload:      payload,
+		FunctionName:   aws.String(functionName),
+		InvocationType: aws.String(invocationType),
+		Payload:        payload,
 	})
 	if err != nil {
 		return err
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
+)
+
+// Metrics turn the consumer loop from a black box into something operable:
+// without these, there's no way to see backlog, throughput, or failure rate
+// short of shelling into Redis.
+var (
+	messagesReadTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "redis_stream_lambda_messages_read_total",
+		Help: "Messages taken up for Lambda invocation, whether read via XREADGROUP or reclaimed from the PEL.",
+	}, []string{"stream"})
+
+	messagesAckedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "redis_stream_lambda_messages_acked_total",
+		Help: "Messages successfully acknowledged back to a stream.",
+	}, []string{"stream"})
+
+	messagesFailedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "redis_stream_lambda_messages_failed_total",
+		Help: "Messages whose payload encoding or Lambda invocation returned an error.",
+	}, []string{"stream"})
+
+	lambdaInvocationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "redis_stream_lambda_invocation_duration_seconds",
+		Help:    "Lambda invocation latency in seconds.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"stream"})
+
+	lambdaErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "redis_stream_lambda_errors_total",
+		Help: "Lambda invocation errors, labeled by AWS error code.",
+	}, []string{"stream", "aws_error_code"})
+
+	pendingEntriesCount = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "redis_stream_lambda_pending_entries",
+		Help: "Pending entries list length for a stream/group, from XPENDING.",
+	}, []string{"stream", "consumer_group"})
+
+	consumerCount = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "redis_stream_lambda_consumers",
+		Help: "Number of consumers registered in a group, from XINFO GROUPS.",
+	}, []string{"stream", "consumer_group"})
+
+	consumerLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "redis_stream_lambda_consumer_lag",
+		Help: "Group lag reported by XINFO GROUPS (Redis 7+; 0 on older servers).",
+	}, []string{"stream", "consumer_group"})
+
+	lastDeliveredTimestamp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "redis_stream_lambda_last_delivered_timestamp_ms",
+		Help: "Unix millisecond timestamp encoded in the group's last-delivered-id.",
+	}, []string{"stream", "consumer_group"})
+)
+
+// startMetricsServer starts the /metrics endpoint in the background when
+// METRICS_ADDR is set. A blank addr disables it entirely.
+func startMetricsServer(addr string) {
+	if addr == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	go func() {
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Printf("Metrics server on %s stopped: %v", addr, err)
+		}
+	}()
+
+	log.Printf("Metrics endpoint listening on %s/metrics", addr)
+}
+
+// loadGroupMetricsInterval reads METRICS_GROUP_POLL_INTERVAL, defaulting to a
+// conservative 15s so XPENDING/XINFO GROUPS polling doesn't add meaningful
+// load to Redis.
+func loadGroupMetricsInterval() time.Duration {
+	interval := 15 * time.Second
+	if v := os.Getenv("METRICS_GROUP_POLL_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			interval = d
+		} else {
+			log.Printf("Invalid METRICS_GROUP_POLL_INTERVAL %q, using default %s: %v", v, interval, err)
+		}
+	}
+	return interval
+}
+
+// runGroupMetricsLoop periodically refreshes the PEL-length, consumer-count,
+// lag, and last-delivered gauges for one stream/group pair. It returns once
+// ctx is cancelled, so callers can wait for it to exit before shutdown.
+func runGroupMetricsLoop(rdb redis.UniversalClient, stream, consumerGroup string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			updateGroupMetrics(rdb, stream, consumerGroup)
+		}
+	}
+}
+
+func updateGroupMetrics(rdb redis.UniversalClient, stream, consumerGroup string) {
+	summary, err := rdb.XPending(ctx, stream, consumerGroup).Result()
+	if err != nil {
+		log.Printf("Error reading XPENDING summary for stream %s group %s: %v", stream, consumerGroup, err)
+	} else {
+		pendingEntriesCount.WithLabelValues(stream, consumerGroup).Set(float64(summary.Count))
+	}
+
+	groups, err := rdb.XInfoGroups(ctx, stream).Result()
+	if err != nil {
+		log.Printf("Error reading XINFO GROUPS for stream %s: %v", stream, err)
+		return
+	}
+
+	for _, group := range groups {
+		if group.Name != consumerGroup {
+			continue
+		}
+
+		consumerCount.WithLabelValues(stream, consumerGroup).Set(float64(group.Consumers))
+		consumerLag.WithLabelValues(stream, consumerGroup).Set(float64(group.Lag))
+
+		if ms, err := streamIDMillis(group.LastDeliveredID); err == nil {
+			lastDeliveredTimestamp.WithLabelValues(stream, consumerGroup).Set(float64(ms))
+		}
+	}
+}
+
+// streamIDMillis extracts the millisecond timestamp prefix from a stream ID
+// of the form "<ms>-<seq>".
+func streamIDMillis(id string) (int64, error) {
+	ms := id
+	if i := strings.IndexByte(id, '-'); i >= 0 {
+		ms = id[:i]
+	}
+	return strconv.ParseInt(ms, 10, 64)
+}
+
+// observeLambdaError classifies a Lambda invocation error by AWS error code
+// (or "unknown" for non-AWS errors) and records it.
+func observeLambdaError(stream string, err error) {
+	code := "unknown"
+	if aerr, ok := err.(awserr.Error); ok {
+		code = aerr.Code()
+	}
+	lambdaErrorsTotal.WithLabelValues(stream, code).Inc()
+}
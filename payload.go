@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// messageEnvelope is the well-defined shape every Lambda invocation payload
+// is built from, regardless of which PayloadEncoder renders it to bytes.
+type messageEnvelope struct {
+	Stream        string                 `json:"stream"`
+	ID            string                 `json:"id"`
+	TimestampMs   int64                  `json:"timestamp_ms"`
+	ConsumerGroup string                 `json:"consumer_group"`
+	Attempt       int64                  `json:"attempt"`
+	Values        map[string]interface{} `json:"values"`
+}
+
+// buildEnvelope assembles a messageEnvelope for a single stream message.
+func buildEnvelope(stream, id string, timestampMs int64, consumerGroup string, attempt int64, values map[string]interface{}) messageEnvelope {
+	return messageEnvelope{
+		Stream:        stream,
+		ID:            id,
+		TimestampMs:   timestampMs,
+		ConsumerGroup: consumerGroup,
+		Attempt:       attempt,
+		Values:        values,
+	}
+}
+
+// PayloadEncoder renders a messageEnvelope to the bytes sent as the Lambda
+// invocation payload. Implementations must produce valid JSON.
+type PayloadEncoder interface {
+	Encode(envelope messageEnvelope) ([]byte, error)
+}
+
+// jsonPayloadEncoder emits the envelope as plain JSON.
+type jsonPayloadEncoder struct{}
+
+func (jsonPayloadEncoder) Encode(envelope messageEnvelope) ([]byte, error) {
+	return json.Marshal(envelope)
+}
+
+// cloudEvent is a CloudEvents 1.0 structured-mode JSON envelope wrapping our
+// messageEnvelope as its data payload.
+// https://github.com/cloudevents/spec/blob/v1.0.2/cloudevents/formats/json-format.md
+type cloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	Type            string          `json:"type"`
+	Source          string          `json:"source"`
+	ID              string          `json:"id"`
+	Time            string          `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	Data            messageEnvelope `json:"data"`
+}
+
+// cloudEventsPayloadEncoder wraps the envelope in a CloudEvents 1.0 structured
+// JSON event, for downstream Lambdas that speak CloudEvents.
+type cloudEventsPayloadEncoder struct{}
+
+func (cloudEventsPayloadEncoder) Encode(envelope messageEnvelope) ([]byte, error) {
+	event := cloudEvent{
+		SpecVersion:     "1.0",
+		Type:            "com.gacerioni.redis-stream-to-aws-lambda.message",
+		Source:          fmt.Sprintf("redis-stream-to-aws-lambda/%s", envelope.Stream),
+		ID:              fmt.Sprintf("%s-%s", envelope.Stream, envelope.ID),
+		Time:            time.UnixMilli(envelope.TimestampMs).UTC().Format(time.RFC3339Nano),
+		DataContentType: "application/json",
+		Data:            envelope,
+	}
+	return json.Marshal(event)
+}
+
+// selectPayloadEncoder picks the PayloadEncoder named by PAYLOAD_FORMAT,
+// defaulting to plain JSON.
+func selectPayloadEncoder() PayloadEncoder {
+	switch os.Getenv("PAYLOAD_FORMAT") {
+	case "cloudevents":
+		return cloudEventsPayloadEncoder{}
+	case "", "json":
+		return jsonPayloadEncoder{}
+	default:
+		log.Printf("Unknown PAYLOAD_FORMAT %q, defaulting to json", os.Getenv("PAYLOAD_FORMAT"))
+		return jsonPayloadEncoder{}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestJSONPayloadEncoderRoundTrip(t *testing.T) {
+	envelope := buildEnvelope("orders", "1690000000000-0", 1690000000000, "order-processors", 1, map[string]interface{}{
+		"quote":   `she said "hello"` + "\n\tand left",
+		"slashes": `C:\path\to\file`,
+		"binary":  []byte{0x00, 0xFF, 0x10, 0x7F},
+	})
+
+	payload, err := (jsonPayloadEncoder{}).Encode(envelope)
+	if err != nil {
+		t.Fatalf("Encode returned error: %v", err)
+	}
+
+	var decoded messageEnvelope
+	if err := json.Unmarshal(payload, &decoded); err != nil {
+		t.Fatalf("payload is not valid JSON: %v\npayload: %s", err, payload)
+	}
+
+	if decoded.Stream != envelope.Stream || decoded.ID != envelope.ID || decoded.ConsumerGroup != envelope.ConsumerGroup {
+		t.Fatalf("envelope fields did not round-trip: got %+v", decoded)
+	}
+
+	if decoded.Values["quote"] != envelope.Values["quote"] {
+		t.Fatalf("quote/newline value did not round-trip: got %q, want %q", decoded.Values["quote"], envelope.Values["quote"])
+	}
+
+	if decoded.Values["slashes"] != envelope.Values["slashes"] {
+		t.Fatalf("backslash value did not round-trip: got %q, want %q", decoded.Values["slashes"], envelope.Values["slashes"])
+	}
+
+	// []byte is base64-encoded by encoding/json, and decodes back to a string
+	// of that base64 text since Values is map[string]interface{}.
+	wantBinary, err := json.Marshal(envelope.Values["binary"])
+	if err != nil {
+		t.Fatalf("failed to marshal expected binary value: %v", err)
+	}
+	gotBinary, err := json.Marshal(decoded.Values["binary"])
+	if err != nil {
+		t.Fatalf("failed to marshal decoded binary value: %v", err)
+	}
+	if string(wantBinary) != string(gotBinary) {
+		t.Fatalf("binary value did not round-trip: got %s, want %s", gotBinary, wantBinary)
+	}
+}
+
+func TestCloudEventsPayloadEncoderRoundTrip(t *testing.T) {
+	envelope := buildEnvelope("orders", "1690000000000-0", 1690000000000, "order-processors", 2, map[string]interface{}{
+		"note": `line one\nline two "quoted"`,
+	})
+
+	payload, err := (cloudEventsPayloadEncoder{}).Encode(envelope)
+	if err != nil {
+		t.Fatalf("Encode returned error: %v", err)
+	}
+
+	var decoded cloudEvent
+	if err := json.Unmarshal(payload, &decoded); err != nil {
+		t.Fatalf("payload is not valid JSON: %v\npayload: %s", err, payload)
+	}
+
+	if decoded.SpecVersion != "1.0" {
+		t.Fatalf("expected specversion 1.0, got %q", decoded.SpecVersion)
+	}
+
+	if decoded.Data.Stream != envelope.Stream || decoded.Data.Values["note"] != envelope.Values["note"] {
+		t.Fatalf("wrapped envelope did not round-trip: got %+v", decoded.Data)
+	}
+}
+
+func TestSelectPayloadEncoderDefaultsToJSON(t *testing.T) {
+	t.Setenv("PAYLOAD_FORMAT", "")
+	if _, ok := selectPayloadEncoder().(jsonPayloadEncoder); !ok {
+		t.Fatalf("expected jsonPayloadEncoder by default")
+	}
+
+	t.Setenv("PAYLOAD_FORMAT", "cloudevents")
+	if _, ok := selectPayloadEncoder().(cloudEventsPayloadEncoder); !ok {
+		t.Fatalf("expected cloudEventsPayloadEncoder for PAYLOAD_FORMAT=cloudevents")
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// reclaimConfig controls how aggressively stalled PEL entries are reclaimed
+// and when they're given up on and sent to the dead-letter stream.
+type reclaimConfig struct {
+	Interval         time.Duration // how often to scan the PEL
+	MinIdleTime      time.Duration // how long an entry must be idle before it's eligible for reclaim
+	MaxDeliveries    int64         // delivery count at which an entry is dead-lettered instead of retried
+	DeadLetterStream string        // destination stream for exhausted entries; dead-lettering disabled if empty
+}
+
+// loadReclaimConfig reads reclaim tuning from the environment, falling back to
+// conservative defaults so the loop is safe to enable without extra config.
+func loadReclaimConfig() reclaimConfig {
+	cfg := reclaimConfig{
+		Interval:         30 * time.Second,
+		MinIdleTime:      60 * time.Second,
+		MaxDeliveries:    5,
+		DeadLetterStream: os.Getenv("DEAD_LETTER_STREAM"),
+	}
+
+	if v := os.Getenv("RECLAIM_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.Interval = d
+		} else {
+			log.Printf("Invalid RECLAIM_INTERVAL %q, using default %s: %v", v, cfg.Interval, err)
+		}
+	}
+
+	if v := os.Getenv("MIN_IDLE_TIME"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.MinIdleTime = d
+		} else {
+			log.Printf("Invalid MIN_IDLE_TIME %q, using default %s: %v", v, cfg.MinIdleTime, err)
+		}
+	}
+
+	if v := os.Getenv("MAX_DELIVERIES"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			cfg.MaxDeliveries = n
+		} else {
+			log.Printf("Invalid MAX_DELIVERIES %q, using default %d", v, cfg.MaxDeliveries)
+		}
+	}
+
+	return cfg
+}
+
+// runReclaimLoop periodically claims PEL entries that have been idle for
+// longer than MinIdleTime, resubmitting each one to pool for an actual retry
+// against Lambda. An entry that has already been delivered MaxDeliveries
+// times is instead published to the dead-letter stream and acked off the
+// original PEL, so a single poison message can't block the stream forever.
+// Running this per stream, keyed only by consumerName, also makes it safe for
+// multiple replicas to share the same consumer group.
+//
+// It returns once ctx is cancelled, so callers can wait for it to exit before
+// tearing down pool — otherwise a reclaim tick racing shutdown could submit
+// to a pool whose jobs channel has already been closed.
+func runReclaimLoop(rdb redis.UniversalClient, stream, consumerGroup, consumerName string, cfg reclaimConfig, pool *lambdaWorkerPool) {
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reclaimOnce(rdb, stream, consumerGroup, consumerName, cfg, pool)
+		}
+	}
+}
+
+// reclaimOnce drains the idle PEL for stream in pages of 100, following
+// XAutoClaim's cursor until it reports no more entries are left to scan.
+func reclaimOnce(rdb redis.UniversalClient, stream, consumerGroup, consumerName string, cfg reclaimConfig, pool *lambdaWorkerPool) {
+	cursor := "0-0"
+	for {
+		claimed, next, err := rdb.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   stream,
+			Group:    consumerGroup,
+			Consumer: consumerName,
+			MinIdle:  cfg.MinIdleTime,
+			Start:    cursor,
+			Count:    100,
+		}).Result()
+		if err != nil {
+			log.Printf("Error auto-claiming pending entries for stream %s: %v", stream, err)
+			return
+		}
+
+		if len(claimed) > 0 {
+			deliveryCounts, err := deliveryCountsFor(rdb, stream, consumerGroup, claimed)
+			if err != nil {
+				log.Printf("Error reading delivery counts for stream %s: %v", stream, err)
+			} else {
+				for _, message := range claimed {
+					count := deliveryCounts[message.ID]
+					if cfg.DeadLetterStream != "" && count >= cfg.MaxDeliveries {
+						deadLetter(rdb, stream, consumerGroup, cfg.DeadLetterStream, message, count)
+						continue
+					}
+					log.Printf("Reclaimed message ID %s on stream %s for consumer %s (delivery count %d), resubmitting", message.ID, stream, consumerName, count)
+					pool.SubmitWithAttempt(stream, message, count)
+				}
+			}
+		}
+
+		if next == "0-0" {
+			return
+		}
+		cursor = next
+	}
+}
+
+// deliveryCountsFor looks up the XPENDING delivery count for each claimed
+// message, scoping each lookup to its exact ID (rather than a Count-bounded
+// window over the whole PEL) so counts can't be mis-attributed to other
+// pending entries the same consumer happens to hold.
+func deliveryCountsFor(rdb redis.UniversalClient, stream, consumerGroup string, claimed []redis.XMessage) (map[string]int64, error) {
+	cmds := make([]*redis.XPendingExtCmd, len(claimed))
+	_, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, message := range claimed {
+			cmds[i] = pipe.XPendingExt(ctx, &redis.XPendingExtArgs{
+				Stream: stream,
+				Group:  consumerGroup,
+				Start:  message.ID,
+				End:    message.ID,
+				Count:  1,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(claimed))
+	for i, cmd := range cmds {
+		entries, err := cmd.Result()
+		if err != nil {
+			log.Printf("Error reading delivery count for message ID %s on stream %s: %v", claimed[i].ID, stream, err)
+			continue
+		}
+		for _, entry := range entries {
+			counts[entry.ID] = entry.RetryCount
+		}
+	}
+	return counts, nil
+}
+
+// deadLetter republishes an exhausted message to the dead-letter stream with
+// failure metadata attached, then acks it off the original stream's PEL so it
+// stops being reclaimed. Metadata keys are namespaced with a "_dlq_" prefix
+// so they can't clobber a payload field of the same name.
+func deadLetter(rdb redis.UniversalClient, stream, consumerGroup, deadLetterStream string, message redis.XMessage, deliveryCount int64) {
+	values := make(map[string]interface{}, len(message.Values)+4)
+	for k, v := range message.Values {
+		values[k] = v
+	}
+	values["_dlq_original_stream"] = stream
+	values["_dlq_original_id"] = message.ID
+	values["_dlq_consumer_group"] = consumerGroup
+	values["_dlq_delivery_count"] = deliveryCount
+
+	if err := rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: deadLetterStream,
+		Values: values,
+	}).Err(); err != nil {
+		log.Printf("Error publishing message ID %s from stream %s to dead-letter stream %s: %v", message.ID, stream, deadLetterStream, err)
+		return
+	}
+
+	if err := rdb.XAck(ctx, stream, consumerGroup, message.ID).Err(); err != nil {
+		log.Printf("Error acking dead-lettered message ID %s on stream %s: %v", message.ID, stream, err)
+		return
+	}
+
+	log.Printf("Message ID %s on stream %s exceeded %d deliveries, sent to dead-letter stream %s", message.ID, stream, deliveryCount, deadLetterStream)
+}
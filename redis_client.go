@@ -0,0 +1,130 @@
+package main
+
+import (
+	"crypto/tls"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// initializeRedisClient builds a redis.UniversalClient for whichever topology
+// this deployment talks to. REDIS_MODE selects standalone, cluster, or
+// sentinel explicitly; if unset, the mode is auto-detected from
+// SENTINEL_MASTER (sentinel) and REDIS_ADDRESSES (cluster when it lists more
+// than one address), defaulting to standalone via REDIS_URL. Returning the
+// UniversalClient interface lets the rest of the consumer loop stay oblivious
+// to which topology it's actually talking to.
+func initializeRedisClient(redisURL string) redis.UniversalClient {
+	addresses := parseAddresses(os.Getenv("REDIS_ADDRESSES"))
+	sentinelMaster := os.Getenv("SENTINEL_MASTER")
+
+	mode := strings.ToLower(os.Getenv("REDIS_MODE"))
+	if mode == "" {
+		switch {
+		case sentinelMaster != "":
+			mode = "sentinel"
+		case len(addresses) > 1:
+			mode = "cluster"
+		default:
+			mode = "standalone"
+		}
+	}
+
+	username := os.Getenv("REDIS_USERNAME")
+	password := os.Getenv("REDIS_PASSWORD")
+	poolSize := envInt("REDIS_POOL_SIZE", 0)
+	tlsConfig := optionalTLSConfig()
+
+	switch mode {
+	case "cluster":
+		if len(addresses) == 0 {
+			log.Fatalf("REDIS_MODE=cluster requires REDIS_ADDRESSES")
+		}
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:     addresses,
+			Username:  username,
+			Password:  password,
+			PoolSize:  poolSize,
+			TLSConfig: tlsConfig,
+		})
+	case "sentinel":
+		if sentinelMaster == "" || len(addresses) == 0 {
+			log.Fatalf("REDIS_MODE=sentinel requires SENTINEL_MASTER and REDIS_ADDRESSES")
+		}
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:    sentinelMaster,
+			SentinelAddrs: addresses,
+			Username:      username,
+			Password:      password,
+			DB:            envInt("REDIS_DB", 0),
+			PoolSize:      poolSize,
+			TLSConfig:     tlsConfig,
+		})
+	case "standalone":
+		opts, err := redis.ParseURL(redisURL)
+		if err != nil {
+			log.Fatalf("Failed to parse Redis URL: %v", err)
+		}
+		if username != "" {
+			opts.Username = username
+		}
+		if password != "" {
+			opts.Password = password
+		}
+		if poolSize > 0 {
+			opts.PoolSize = poolSize
+		}
+		if tlsConfig != nil {
+			opts.TLSConfig = tlsConfig
+		}
+		return redis.NewClient(opts)
+	default:
+		log.Fatalf("Unknown REDIS_MODE %q, expected standalone, cluster, or sentinel", mode)
+		return nil
+	}
+}
+
+// parseAddresses splits a comma-separated REDIS_ADDRESSES value into a
+// trimmed, non-empty address list.
+func parseAddresses(addresses string) []string {
+	if addresses == "" {
+		return nil
+	}
+	parts := strings.Split(addresses, ",")
+	result := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if trimmed := strings.TrimSpace(p); trimmed != "" {
+			result = append(result, trimmed)
+		}
+	}
+	return result
+}
+
+// envInt reads an integer environment variable, returning fallback if it's
+// unset or invalid.
+func envInt(key string, fallback int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("Invalid %s %q, using default %d", key, v, fallback)
+		return fallback
+	}
+	return n
+}
+
+// optionalTLSConfig returns a TLS config when REDIS_TLS is truthy, nil
+// otherwise. Standard certificate verification applies; operators needing
+// custom CAs should terminate TLS upstream of this process.
+func optionalTLSConfig() *tls.Config {
+	enabled, err := strconv.ParseBool(os.Getenv("REDIS_TLS"))
+	if err != nil || !enabled {
+		return nil
+	}
+	return &tls.Config{}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+// loadReadBlockTimeout reads READ_BLOCK_TIMEOUT, the XREADGROUP Block
+// duration. A bounded block (instead of blocking forever) is what lets the
+// read loop wake up and notice a cancelled context.
+func loadReadBlockTimeout() time.Duration {
+	timeout := 5 * time.Second
+	if v := os.Getenv("READ_BLOCK_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			timeout = d
+		} else {
+			log.Printf("Invalid READ_BLOCK_TIMEOUT %q, using default %s: %v", v, timeout, err)
+		}
+	}
+	return timeout
+}
+
+// loadShutdownDeadline reads SHUTDOWN_DEADLINE, how long graceful shutdown
+// waits for in-flight Lambda invocations before giving up and exiting anyway.
+func loadShutdownDeadline() time.Duration {
+	deadline := 30 * time.Second
+	if v := os.Getenv("SHUTDOWN_DEADLINE"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			deadline = d
+		} else {
+			log.Printf("Invalid SHUTDOWN_DEADLINE %q, using default %s: %v", v, deadline, err)
+		}
+	}
+	return deadline
+}